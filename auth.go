@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// TLSConfig configures how the exporter validates and authenticates
+// itself to a scraped BIND stats channel served over HTTPS
+// (Module.TLSConfig). The exporter's own /metrics listener does not use
+// it; that listener's TLS is set by the -web.tls-cert/-web.tls-key flags.
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// BasicAuth holds a single set of HTTP basic auth credentials.
+type BasicAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// buildTLSConfig turns a TLSConfig into a *tls.Config, or returns a nil
+// *tls.Config (use Go's defaults) if cfg is the zero value.
+func buildTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	if cfg == (TLSConfig{}) {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if cfg.CAFile != "" {
+		ca, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read ca_file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("could not parse any certificates from ca_file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client cert/key: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// basicAuthTransport injects HTTP basic auth credentials into every
+// outgoing request before handing it off to the wrapped RoundTripper.
+type basicAuthTransport struct {
+	auth    BasicAuth
+	wrapped http.RoundTripper
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.auth.Username, t.auth.Password)
+	return t.wrapped.RoundTrip(req)
+}
+
+// basicAuthHandler wraps an http.Handler with HTTP basic auth, used to
+// protect the exporter's own /metrics endpoint.
+func basicAuthHandler(auth BasicAuth, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(auth.Username)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(auth.Password)) == 1
+		if !ok || !userOK || !passOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="bind_exporter"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestBasicAuthHandler(t *testing.T) {
+	auth := BasicAuth{Username: "alice", Password: "s3cret"}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := basicAuthHandler(auth, next)
+
+	tests := []struct {
+		name       string
+		user, pass string
+		setHeader  bool
+		wantStatus int
+	}{
+		{"correct credentials", "alice", "s3cret", true, http.StatusOK},
+		{"wrong username", "mallory", "s3cret", true, http.StatusUnauthorized},
+		{"wrong password", "alice", "wrong", true, http.StatusUnauthorized},
+		{"missing header", "", "", false, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.setHeader {
+				req.SetBasicAuth(tt.user, tt.pass)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	t.Run("zero value returns nil config", func(t *testing.T) {
+		cfg, err := buildTLSConfig(TLSConfig{})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if cfg != nil {
+			t.Fatalf("expected nil *tls.Config, got %+v", cfg)
+		}
+	})
+
+	t.Run("bad ca_file errors", func(t *testing.T) {
+		_, err := buildTLSConfig(TLSConfig{CAFile: filepath.Join(t.TempDir(), "does-not-exist.pem")})
+		if err == nil {
+			t.Fatal("expected an error for a missing ca_file")
+		}
+	})
+
+	t.Run("ca_file with no valid certificates errors", func(t *testing.T) {
+		dir := t.TempDir()
+		caFile := filepath.Join(dir, "ca.pem")
+		if err := ioutil.WriteFile(caFile, []byte("not a certificate"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := buildTLSConfig(TLSConfig{CAFile: caFile})
+		if err == nil {
+			t.Fatal("expected an error for a ca_file with no parseable certificates")
+		}
+	})
+
+	t.Run("bad cert/key pair errors", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile := filepath.Join(dir, "cert.pem")
+		keyFile := filepath.Join(dir, "key.pem")
+		if err := ioutil.WriteFile(certFile, []byte("not a cert"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(keyFile, []byte("not a key"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := buildTLSConfig(TLSConfig{CertFile: certFile, KeyFile: keyFile})
+		if err == nil {
+			t.Fatal("expected an error for an invalid cert/key pair")
+		}
+	})
+}
+
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"flag"
@@ -10,12 +11,14 @@ import (
 	"net"
 	"net/http"
 	_ "net/http/pprof"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/log"
 )
 
@@ -152,32 +155,94 @@ var (
 // Exporter collects Binds stats from the given server and exports
 // them using the prometheus metrics package.
 type Exporter struct {
-	URI     string
-	metrics []string
-	version string
-	client  *http.Client
+	URI         string
+	metrics     []string
+	version     string
+	statsFormat string
+	zoneFilter  *regexp.Regexp
+	client      *http.Client
+
+	totalScrapes     prometheus.Counter
+	scrapeFailures   *prometheus.CounterVec
+	xmlParseFailures prometheus.Counter
+	scrapeDuration   *prometheus.HistogramVec
 }
 
-// NewExporter returns an initialized Exporter.
-func NewExporter(URI string, metrics []string, timeout time.Duration) *Exporter {
-	return &Exporter{
-		URI:     URI,
-		metrics: metrics,
-		client: &http.Client{
-			Transport: &http.Transport{
-				Dial: func(netw, addr string) (net.Conn, error) {
-					c, err := net.DialTimeout(netw, addr, timeout)
-					if err != nil {
-						return nil, err
-					}
-					if err := c.SetDeadline(time.Now().Add(timeout)); err != nil {
-						return nil, err
-					}
-					return c, nil
-				},
-			},
+// ExporterOpts configures NewExporter. It grew out of a plain parameter
+// list once TLS/basic-auth made that list unwieldy.
+type ExporterOpts struct {
+	Metrics     []string
+	Timeout     time.Duration
+	StatsFormat string
+	ZoneFilter  *regexp.Regexp
+	TLSConfig   TLSConfig
+	BasicAuth   *BasicAuth
+}
+
+// NewExporter returns an initialized Exporter. opts.StatsFormat selects
+// which statistics channel encoding to use ("auto", "xml" or "json");
+// "auto" probes the JSON channel first and falls back to XML.
+// opts.ZoneFilter, if non-nil, restricts per-zone metrics to zones whose
+// name matches it. opts.TLSConfig and opts.BasicAuth configure how the
+// exporter authenticates to URI, for BIND stats channels fronted by
+// stunnel or an authenticating reverse proxy.
+func NewExporter(URI string, opts ExporterOpts) (*Exporter, error) {
+	tlsConfig, err := buildTLSConfig(opts.TLSConfig)
+	if err != nil {
+		return nil, fmt.Errorf("could not configure TLS for %s: %s", URI, err)
+	}
+
+	transport := &http.Transport{
+		Dial: func(netw, addr string) (net.Conn, error) {
+			c, err := net.DialTimeout(netw, addr, opts.Timeout)
+			if err != nil {
+				return nil, err
+			}
+			if err := c.SetDeadline(time.Now().Add(opts.Timeout)); err != nil {
+				return nil, err
+			}
+			return c, nil
 		},
+		TLSClientConfig: tlsConfig,
 	}
+
+	var roundTripper http.RoundTripper = transport
+	if opts.BasicAuth != nil {
+		roundTripper = &basicAuthTransport{auth: *opts.BasicAuth, wrapped: transport}
+	}
+
+	return &Exporter{
+		URI:         URI,
+		metrics:     opts.Metrics,
+		statsFormat: opts.StatsFormat,
+		zoneFilter:  opts.ZoneFilter,
+		client:      &http.Client{Transport: roundTripper},
+		totalScrapes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "total_scrapes",
+			Help:      "Current total BIND scrapes.",
+		}),
+		scrapeFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "scrape_failures_total",
+			Help:      "Number of errors while scraping BIND, by stage.",
+		}, []string{"stage"}),
+		xmlParseFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "xml_parse_failures_total",
+			Help:      "Number of XML documents returned by BIND that failed to unmarshal.",
+		}),
+		scrapeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "scrape_duration_seconds",
+			Help:      "Duration of a single BIND sub-metric fetch, by sub-metric name.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"metric"}),
+	}, nil
 }
 
 func (e *Exporter) GetV3URI(metric string) string {
@@ -188,14 +253,46 @@ func (e *Exporter) GetV3URI(metric string) string {
 	}
 }
 
+// GetJSONURI returns the URI of a JSON statistics sub-resource (BIND
+// 9.10+), mirroring GetV3URI for the XML v3 channel.
+func (e *Exporter) GetJSONURI(metric string) string {
+	if e.URI[len(e.URI)-1] == byte('/') {
+		return e.URI + "json/v1/" + metric
+	}
+	return e.URI + "/json/v1/" + metric
+}
+
+// probeJSON reports whether the server answers on the JSON statistics
+// channel, used to decide the "auto" stats format.
+func (e *Exporter) probeJSON() bool {
+	resp, err := e.client.Get(e.GetJSONURI("status"))
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
 func (e *Exporter) GetVersion() (string, error) {
 	// cached, use that
 	if e.version != "" {
 		return e.version, nil
 	}
 
+	if e.statsFormat == "json" {
+		e.version = "json"
+		return e.version, nil
+	}
+
+	if e.statsFormat == "auto" && e.probeJSON() {
+		e.version = "json"
+		return e.version, nil
+	}
+
 	resp, err := e.client.Get(e.GetV3URI("status"))
 	if err != nil {
+		e.scrapeFailures.WithLabelValues("version").Inc()
 		log.Error("Error while querying Bind: ", err)
 		return "", err
 	}
@@ -204,6 +301,7 @@ func (e *Exporter) GetVersion() (string, error) {
 
 	if resp.StatusCode >= 500 {
 		// 5xx is for server errors, abort search
+		e.scrapeFailures.WithLabelValues("version").Inc()
 		log.Error("Error while querying Bind: ", resp.Status)
 		return "", errors.New(resp.Status)
 	}
@@ -231,11 +329,21 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- serverReponses
 	ch <- tasksRunning
 	ch <- workerThreads
+	ch <- zoneSerial
+	ch <- zoneTransferSuccess
+	ch <- zoneTransferFailure
+	ch <- zoneNotifyOut
+	ch <- zoneJournalSize
+	e.totalScrapes.Describe(ch)
+	e.scrapeFailures.Describe(ch)
+	e.xmlParseFailures.Describe(ch)
+	e.scrapeDuration.Describe(ch)
 }
 
 func (e *Exporter) Fetch(uri string) ([]byte, error) {
 	resp, err := e.client.Get(uri)
 	if err != nil {
+		e.scrapeFailures.WithLabelValues("fetch").Inc()
 		return nil, err
 	}
 
@@ -243,6 +351,7 @@ func (e *Exporter) Fetch(uri string) ([]byte, error) {
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
+		e.scrapeFailures.WithLabelValues("fetch").Inc()
 		return nil, err
 	}
 
@@ -257,6 +366,8 @@ func (e *Exporter) FetchV2() (*BindRootV2, error) {
 
 	root := BindRootV2{}
 	if err := xml.Unmarshal(data, &root); err != nil {
+		e.scrapeFailures.WithLabelValues("unmarshal").Inc()
+		e.xmlParseFailures.Inc()
 		return nil, err
 	}
 
@@ -267,12 +378,16 @@ func (e *Exporter) FetchV3() (*BindRootV3, error) {
 	root := BindRootV3{}
 
 	for _, metric := range e.metrics {
+		start := time.Now()
 		body, err := e.Fetch(e.GetV3URI(metric))
+		e.scrapeDuration.WithLabelValues(metric).Observe(time.Since(start).Seconds())
 		if err != nil {
 			return nil, err
 		}
 
 		if err := xml.Unmarshal(body, &root); err != nil {
+			e.scrapeFailures.WithLabelValues("unmarshal").Inc()
+			e.xmlParseFailures.Inc()
 			return nil, err
 		}
 	}
@@ -282,6 +397,31 @@ func (e *Exporter) FetchV3() (*BindRootV3, error) {
 	return &root, nil
 }
 
+// FetchJSON is the JSON-statistics-channel counterpart to FetchV3. It is
+// cheaper than the XML path since it skips DOM unmarshalling of the full
+// document for every sub-metric group.
+func (e *Exporter) FetchJSON() (*BindJSONRoot, error) {
+	root := BindJSONRoot{}
+
+	for _, metric := range e.metrics {
+		start := time.Now()
+		body, err := e.Fetch(e.GetJSONURI(metric))
+		e.scrapeDuration.WithLabelValues(metric).Observe(time.Since(start).Seconds())
+		if err != nil {
+			return nil, err
+		}
+
+		scratch := BindJSONRoot{}
+		if err := json.Unmarshal(body, &scratch); err != nil {
+			e.scrapeFailures.WithLabelValues("unmarshal").Inc()
+			return nil, err
+		}
+		root.merge(scratch)
+	}
+
+	return &root, nil
+}
+
 func (e *Exporter) UpdateV2(ch chan<- prometheus.Metric, root BindRootV2) {
 	stats := root.Bind.Statistics
 	for _, s := range stats.Server.QueriesIn.Rdtype {
@@ -418,6 +558,8 @@ func (e *Exporter) UpdateV3(ch chan<- prometheus.Metric, root BindRootV3) {
 				}
 			}
 		}
+
+		e.updateZonesV3(ch, v.Name, v.Zones)
 	}
 	threadModel := root.Taskmgr.ThreadModel
 	ch <- prometheus.MustNewConstMetric(
@@ -432,8 +574,13 @@ func (e *Exporter) UpdateV3(ch chan<- prometheus.Metric, root BindRootV3) {
 // delivers them as Prometheus metrics. It implements prometheus.Collector.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	var status float64
+	e.totalScrapes.Inc()
 	defer func() {
 		ch <- prometheus.MustNewConstMetric(up, prometheus.GaugeValue, status)
+		e.totalScrapes.Collect(ch)
+		e.scrapeFailures.Collect(ch)
+		e.xmlParseFailures.Collect(ch)
+		e.scrapeDuration.Collect(ch)
 	}()
 
 	version, err := e.GetVersion()
@@ -443,6 +590,17 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 		return
 	}
 
+	if version == "json" {
+		root, err := e.FetchJSON()
+		if err != nil {
+			log.Error("Failed to fetch/unmarshal JSON: ", err)
+			return
+		}
+		status = 1
+		e.UpdateJSON(ch, *root)
+		return
+	}
+
 	if version == "v3" {
 		root, err := e.FetchV3()
 		if err != nil {
@@ -532,10 +690,69 @@ func main() {
 		bindURI       = flag.String("bind.statsuri", "http://localhost:8053/", "HTTP XML API address of an Bind server.")
 		bindTimeout   = flag.Duration("bind.timeout", 10*time.Second, "Timeout for trying to get stats from Bind.")
 		bindPidFile   = flag.String("bind.pid-file", "", "Path to Bind's pid file to export process information.")
+		dnstapSocket  = flag.String("bind.dnstap-socket", "", "Path to a Unix socket to listen for dnstap messages on (disabled if empty).")
+		dnstapPairTTL = flag.Duration("bind.dnstap-pair-ttl", 10*time.Second, "How long to wait for a dnstap CLIENT_RESPONSE to pair with its CLIENT_QUERY.")
+		configFile    = flag.String("config.file", "", "Path to a YAML file defining /probe modules (defaults to a single \"default\" module built from bind.metrics/bind.timeout).")
+		statsFormat   = flag.String("bind.stats-format", "auto", "Statistics channel encoding to use: auto, xml or json.")
+		zoneFilter    = flag.String("bind.zone-filter", "", "Regexp of zone names to export per-zone metrics for (default: all zones).")
+		webTLSCert    = flag.String("web.tls-cert", "", "Path to a TLS certificate file to serve /metrics over HTTPS (disabled if empty).")
+		webTLSKey     = flag.String("web.tls-key", "", "Path to the TLS private key matching web.tls-cert.")
 	)
 	flag.Parse()
 
-	prometheus.MustRegister(NewExporter(*bindURI, strings.Split(*subMetrics, ","), *bindTimeout))
+	probeConfig.Modules = map[string]Module{
+		DefaultModuleName: {
+			Timeout:     *bindTimeout,
+			Metrics:     strings.Split(*subMetrics, ","),
+			StatsFormat: *statsFormat,
+			ZoneFilter:  *zoneFilter,
+		},
+	}
+	if *configFile != "" {
+		cfg, err := LoadConfig(*configFile)
+		if err != nil {
+			log.Fatal("Error loading config.file: ", err)
+		}
+		if _, ok := cfg.Modules[DefaultModuleName]; !ok {
+			// config.file doesn't define "default": keep serving /metrics
+			// from the bind.* flags instead of falling back to a zero Module.
+			if cfg.Modules == nil {
+				cfg.Modules = map[string]Module{}
+			}
+			cfg.Modules[DefaultModuleName] = probeConfig.Modules[DefaultModuleName]
+		}
+		probeConfig = cfg
+	}
+
+	defaultModule, ok := probeConfig.Module(DefaultModuleName)
+	if !ok {
+		log.Fatal("No \"default\" module configured for /metrics")
+	}
+	compiledZoneFilter, err := compileZoneFilter(defaultModule.ZoneFilter)
+	if err != nil {
+		log.Fatal("Invalid zone_filter: ", err)
+	}
+
+	exporter, err := NewExporter(*bindURI, ExporterOpts{
+		Metrics:     defaultModule.Metrics,
+		Timeout:     defaultModule.Timeout,
+		StatsFormat: defaultModule.StatsFormat,
+		ZoneFilter:  compiledZoneFilter,
+		TLSConfig:   defaultModule.TLSConfig,
+		BasicAuth:   defaultModule.BasicAuth,
+	})
+	if err != nil {
+		log.Fatal("Error creating exporter: ", err)
+	}
+	prometheus.MustRegister(exporter)
+
+	if *dnstapSocket != "" {
+		dnstapCollector, err := NewDnstapCollector(*dnstapSocket, *dnstapPairTTL)
+		if err != nil {
+			log.Fatal("Can't start dnstap collector: ", err)
+		}
+		prometheus.MustRegister(dnstapCollector)
+	}
 	if *bindPidFile != "" {
 		procExporter := prometheus.NewProcessCollectorPIDFn(
 			func() (int, error) {
@@ -552,8 +769,14 @@ func main() {
 		prometheus.MustRegister(procExporter)
 	}
 
+	var metricsHandler http.Handler = promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.Handler())
+	if probeConfig.Web.BasicAuth != nil {
+		metricsHandler = basicAuthHandler(*probeConfig.Web.BasicAuth, metricsHandler)
+	}
+
 	log.Info("Starting Server: ", *listenAddress)
-	http.Handle(*metricsPath, prometheus.Handler())
+	http.Handle(*metricsPath, metricsHandler)
+	http.HandleFunc("/probe", probeHandler)
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
              <head><title>Bind Exporter</title></head>
@@ -563,5 +786,9 @@ func main() {
              </body>
              </html>`))
 	})
+
+	if *webTLSCert != "" && *webTLSKey != "" {
+		log.Fatal(http.ListenAndServeTLS(*listenAddress, *webTLSCert, *webTLSKey, nil))
+	}
 	log.Fatal(http.ListenAndServe(*listenAddress, nil))
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Module describes one named probe configuration: which sub-metrics to
+// fetch, how long to wait before giving up, and how to reach the target
+// BIND stats channel if it sits behind TLS or HTTP basic auth.
+type Module struct {
+	Timeout     time.Duration `yaml:"timeout"`
+	Metrics     []string      `yaml:"metrics"`
+	StatsFormat string        `yaml:"stats_format"`
+	ZoneFilter  string        `yaml:"zone_filter"`
+	TLSConfig   TLSConfig     `yaml:"tls_config"`
+	BasicAuth   *BasicAuth    `yaml:"basic_auth"`
+}
+
+// WebConfig configures the exporter's own /metrics listener.
+type WebConfig struct {
+	BasicAuth *BasicAuth `yaml:"basic_auth"`
+}
+
+// Config is the top level of the -config.file YAML document. It maps
+// module names (as referenced by /probe?module=...) to their settings,
+// plus the settings for the exporter's own /metrics endpoint.
+type Config struct {
+	Modules map[string]Module `yaml:"modules"`
+	Web     WebConfig         `yaml:"web"`
+}
+
+// DefaultModuleName is used when a /probe request does not specify a
+// module and also names the fallback module synthesized from the
+// bind.metrics/bind.timeout flags when no -config.file is given.
+const DefaultModuleName = "default"
+
+// LoadConfig reads and parses a probe module configuration file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %s", path, err)
+	}
+
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %s", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Module looks up a named module; an empty name selects
+// DefaultModuleName, and an unknown name reports ok=false. A module with
+// no stats_format is returned with StatsFormat "auto".
+func (c *Config) Module(name string) (Module, bool) {
+	if name == "" {
+		name = DefaultModuleName
+	}
+	m, ok := c.Modules[name]
+	if ok && m.StatsFormat == "" {
+		m.StatsFormat = "auto"
+	}
+	return m, ok
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dnstap/golang-dnstap"
+	"github.com/golang/protobuf/proto"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const dnstapSubsystem = "dnstap"
+
+var (
+	dnstapQueriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: dnstapSubsystem,
+			Name:      "queries_total",
+			Help:      "Number of DNS queries observed on the dnstap channel, by QNAME suffix.",
+		},
+		[]string{"qname_suffix"},
+	)
+	dnstapResponsesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: dnstapSubsystem,
+			Name:      "responses_total",
+			Help:      "Number of DNS responses observed on the dnstap channel, by response code and client subnet.",
+		},
+		[]string{"rcode", "client_subnet"},
+	)
+	dnstapResponseSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: dnstapSubsystem,
+			Name:      "response_size_bytes",
+			Help:      "Size of DNS responses observed on the dnstap channel.",
+			Buckets:   prometheus.ExponentialBuckets(64, 2, 8),
+		},
+		[]string{"rcode"},
+	)
+	dnstapQueryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: dnstapSubsystem,
+			Name:      "query_duration_seconds",
+			Help:      "Time between a CLIENT_QUERY and its paired CLIENT_RESPONSE frame.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"rcode"},
+	)
+	dnstapUnpairedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: dnstapSubsystem,
+			Name:      "unpaired_responses_total",
+			Help:      "Number of CLIENT_RESPONSE frames that had no matching query, either because it was never seen or because it already expired from the pairing cache.",
+		},
+	)
+)
+
+// pairKey identifies a single in-flight query so that its CLIENT_RESPONSE
+// frame can be matched back up with the CLIENT_QUERY that started it.
+type pairKey struct {
+	client string
+	qid    uint16
+	qname  string
+}
+
+type pairEntry struct {
+	key       pairKey
+	queryTime time.Time
+}
+
+// pairCache is a time-bounded LRU of in-flight queries, keyed by
+// (client, query id, qname). Entries older than ttl are swept away so a
+// response that never arrives cannot leak memory.
+type pairCache struct {
+	mu  sync.Mutex
+	ttl time.Duration
+	ll  *list.List
+	idx map[pairKey]*list.Element
+}
+
+func newPairCache(ttl time.Duration) *pairCache {
+	return &pairCache{
+		ttl: ttl,
+		ll:  list.New(),
+		idx: make(map[pairKey]*list.Element),
+	}
+}
+
+func (c *pairCache) put(k pairKey, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.idx[k]; ok {
+		c.ll.Remove(el)
+	}
+	c.idx[k] = c.ll.PushBack(&pairEntry{key: k, queryTime: t})
+}
+
+// take returns the query time for k and removes it from the cache, or
+// reports ok=false if no query is on file. It does not check the entry's
+// age; only entries already removed by sweep are treated as expired.
+func (c *pairCache) take(k pairKey) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.idx[k]
+	if !ok {
+		return time.Time{}, false
+	}
+	c.ll.Remove(el)
+	delete(c.idx, k)
+	return el.Value.(*pairEntry).queryTime, true
+}
+
+func (c *pairCache) sweep(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		el := c.ll.Front()
+		if el == nil {
+			break
+		}
+		entry := el.Value.(*pairEntry)
+		if now.Sub(entry.queryTime) < c.ttl {
+			break
+		}
+		c.ll.Remove(el)
+		delete(c.idx, entry.key)
+	}
+}
+
+// DnstapCollector consumes a BIND dnstap framestream channel and exposes
+// metrics derived from the decoded query/response frames. Unlike Exporter
+// it does not poll: it maintains its own Prometheus collectors and feeds
+// them as frames arrive, so Collect only needs to forward them on.
+type DnstapCollector struct {
+	input *dnstap.FrameStreamSockInput
+	pairs *pairCache
+}
+
+// NewDnstapCollector opens a framestream listener on socketPath and starts
+// decoding dnstap Message frames in the background. pairTTL bounds how long
+// a CLIENT_QUERY is kept waiting for its CLIENT_RESPONSE.
+func NewDnstapCollector(socketPath string, pairTTL time.Duration) (*DnstapCollector, error) {
+	input, err := dnstap.NewFrameStreamSockInputFromPath(socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open dnstap socket %s: %s", socketPath, err)
+	}
+
+	c := &DnstapCollector{
+		input: input,
+		pairs: newPairCache(pairTTL),
+	}
+
+	frames := make(chan []byte)
+	go input.ReadInto(frames)
+	go c.decodeLoop(frames)
+	go c.sweepLoop(pairTTL)
+
+	return c, nil
+}
+
+func (c *DnstapCollector) sweepLoop(ttl time.Duration) {
+	interval := ttl / 2
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for range time.Tick(interval) {
+		c.pairs.sweep(time.Now())
+	}
+}
+
+func (c *DnstapCollector) decodeLoop(frames chan []byte) {
+	dt := &dnstap.Dnstap{}
+	for frame := range frames {
+		dt.Reset()
+		if err := proto.Unmarshal(frame, dt); err != nil {
+			log.Warn("Error decoding dnstap frame: ", err)
+			continue
+		}
+		c.handleMessage(dt.Message)
+	}
+}
+
+func (c *DnstapCollector) handleMessage(msg *dnstap.Message) {
+	if msg == nil {
+		return
+	}
+
+	switch msg.GetType() {
+	case dnstap.Message_CLIENT_QUERY:
+		c.handleQuery(msg)
+	case dnstap.Message_CLIENT_RESPONSE:
+		c.handleResponse(msg)
+	}
+}
+
+func (c *DnstapCollector) handleQuery(msg *dnstap.Message) {
+	q := new(dns.Msg)
+	if err := q.Unpack(msg.GetQueryMessage()); err != nil {
+		log.Warn("Error unpacking dnstap query message: ", err)
+		return
+	}
+
+	qname := "."
+	if len(q.Question) > 0 {
+		qname = q.Question[0].Name
+	}
+
+	dnstapQueriesTotal.WithLabelValues(qnameSuffix(qname)).Inc()
+
+	queryTime := time.Unix(int64(msg.GetQueryTimeSec()), int64(msg.GetQueryTimeNsec()))
+	c.pairs.put(pairKey{
+		client: clientAddr(msg.GetQueryAddress()),
+		qid:    q.Id,
+		qname:  qname,
+	}, queryTime)
+}
+
+func (c *DnstapCollector) handleResponse(msg *dnstap.Message) {
+	r := new(dns.Msg)
+	if err := r.Unpack(msg.GetResponseMessage()); err != nil {
+		log.Warn("Error unpacking dnstap response message: ", err)
+		return
+	}
+
+	qname := "."
+	if len(r.Question) > 0 {
+		qname = r.Question[0].Name
+	}
+	rcode := dns.RcodeToString[r.Rcode]
+
+	dnstapResponsesTotal.WithLabelValues(rcode, clientSubnet(msg.GetQueryAddress())).Inc()
+	dnstapResponseSize.WithLabelValues(rcode).Observe(float64(len(msg.GetResponseMessage())))
+
+	key := pairKey{
+		client: clientAddr(msg.GetQueryAddress()),
+		qid:    r.Id,
+		qname:  qname,
+	}
+	queryTime, ok := c.pairs.take(key)
+	if !ok {
+		dnstapUnpairedTotal.Inc()
+		return
+	}
+
+	responseTime := time.Unix(int64(msg.GetResponseTimeSec()), int64(msg.GetResponseTimeNsec()))
+	dnstapQueryDuration.WithLabelValues(rcode).Observe(responseTime.Sub(queryTime).Seconds())
+}
+
+// qnameSuffix reduces a QNAME down to its last two labels so that
+// per-name cardinality stays bounded on busy resolvers.
+func qnameSuffix(qname string) string {
+	labels := dns.SplitDomainName(qname)
+	if len(labels) == 0 {
+		return "."
+	}
+	if len(labels) == 1 {
+		return labels[0] + "."
+	}
+	return strings.Join(labels[len(labels)-2:], ".") + "."
+}
+
+// clientAddr renders the raw dnstap query address as a plain IP string.
+func clientAddr(raw []byte) string {
+	ip := net.IP(raw)
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// clientSubnet buckets a client address down to a /24 (IPv4) or /48
+// (IPv6) so per-client cardinality stays bounded.
+func clientSubnet(raw []byte) string {
+	ip := net.IP(raw)
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		mask := net.CIDRMask(24, 32)
+		return (&net.IPNet{IP: v4.Mask(mask), Mask: mask}).String()
+	}
+	mask := net.CIDRMask(48, 128)
+	return (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+}
+
+// Describe implements prometheus.Collector.
+func (c *DnstapCollector) Describe(ch chan<- *prometheus.Desc) {
+	dnstapQueriesTotal.Describe(ch)
+	dnstapResponsesTotal.Describe(ch)
+	dnstapResponseSize.Describe(ch)
+	dnstapQueryDuration.Describe(ch)
+	dnstapUnpairedTotal.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *DnstapCollector) Collect(ch chan<- prometheus.Metric) {
+	dnstapQueriesTotal.Collect(ch)
+	dnstapResponsesTotal.Collect(ch)
+	dnstapResponseSize.Collect(ch)
+	dnstapQueryDuration.Collect(ch)
+	dnstapUnpairedTotal.Collect(ch)
+}
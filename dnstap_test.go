@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQnameSuffix(t *testing.T) {
+	tests := []struct {
+		qname string
+		want  string
+	}{
+		{"www.example.com.", "example.com."},
+		{"example.com.", "example.com."},
+		{"com.", "com."},
+		{".", "."},
+		{"", "."},
+	}
+
+	for _, tt := range tests {
+		if got := qnameSuffix(tt.qname); got != tt.want {
+			t.Errorf("qnameSuffix(%q) = %q, want %q", tt.qname, got, tt.want)
+		}
+	}
+}
+
+func TestClientAddr(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		want string
+	}{
+		{"ipv4", []byte{192, 0, 2, 1}, "192.0.2.1"},
+		{"ipv6", []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}, "2001:db8::1"},
+		{"nil", nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := clientAddr(tt.raw); got != tt.want {
+			t.Errorf("%s: clientAddr(%v) = %q, want %q", tt.name, tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestClientSubnet(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		want string
+	}{
+		{"ipv4", []byte{192, 0, 2, 200}, "192.0.2.0/24"},
+		{"ipv6", []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 1}, "2001:db8::/48"},
+		{"nil", nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := clientSubnet(tt.raw); got != tt.want {
+			t.Errorf("%s: clientSubnet(%v) = %q, want %q", tt.name, tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestPairCachePutTake(t *testing.T) {
+	c := newPairCache(time.Minute)
+	key := pairKey{client: "192.0.2.1", qid: 1, qname: "example.com."}
+	now := time.Unix(1000, 0)
+
+	if _, ok := c.take(key); ok {
+		t.Fatal("expected take on empty cache to report not-found")
+	}
+
+	c.put(key, now)
+	got, ok := c.take(key)
+	if !ok {
+		t.Fatal("expected take to find the entry just put")
+	}
+	if !got.Equal(now) {
+		t.Errorf("got query time %v, want %v", got, now)
+	}
+
+	if _, ok := c.take(key); ok {
+		t.Fatal("expected take to remove the entry, second take should miss")
+	}
+}
+
+func TestPairCacheSweepExpiresOldEntries(t *testing.T) {
+	ttl := time.Minute
+	c := newPairCache(ttl)
+	base := time.Unix(1000, 0)
+
+	oldKey := pairKey{client: "192.0.2.1", qid: 1, qname: "old.example."}
+	freshKey := pairKey{client: "192.0.2.1", qid: 2, qname: "fresh.example."}
+	c.put(oldKey, base)
+	c.put(freshKey, base.Add(ttl/2))
+
+	c.sweep(base.Add(ttl + time.Second))
+
+	if _, ok := c.take(oldKey); ok {
+		t.Error("expected old entry to have been swept")
+	}
+	if _, ok := c.take(freshKey); !ok {
+		t.Error("expected fresh entry to survive the sweep")
+	}
+}
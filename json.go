@@ -0,0 +1,211 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// BindJSONRoot mirrors the subset of BIND's JSON statistics (v1) document
+// that UpdateJSON needs. Unlike BindRootV3, it cannot be filled in by
+// repeatedly json.Unmarshal-ing sub-resource responses into the same
+// root: encoding/json replaces map values wholesale rather than merging
+// into them, so decoding "zones" after "server" would wipe out the view's
+// resolver stats. FetchJSON instead decodes each response into a scratch
+// BindJSONRoot and folds it into the accumulated one with merge.
+type BindJSONRoot struct {
+	Opcodes map[string]uint64       `json:"opcodes"`
+	Qtypes  map[string]uint64       `json:"qtypes"`
+	Nsstats map[string]uint64       `json:"nsstats"`
+	Views   map[string]BindJSONView `json:"views"`
+	Taskmgr BindJSONTaskmgr         `json:"taskmgr"`
+}
+
+type BindJSONView struct {
+	Resolver BindJSONResolver        `json:"resolver"`
+	Zones    map[string]BindJSONZone `json:"zones"`
+}
+
+// BindJSONZone is the JSON-statistics-channel counterpart to ZoneV3.
+type BindJSONZone struct {
+	Serial       uint64            `json:"serial"`
+	NotifyOut    uint64            `json:"notifyout"`
+	JournalSize  uint64            `json:"journalsize"`
+	XfrInSuccess map[string]uint64 `json:"xfrinsuccess"`
+	XfrInFailure map[string]uint64 `json:"xfrinfailure"`
+}
+
+type BindJSONResolver struct {
+	Cache  map[string]uint64 `json:"cache"`
+	Qtypes map[string]uint64 `json:"qtypesperview"`
+	Stats  map[string]uint64 `json:"stats"`
+}
+
+type BindJSONTaskmgr struct {
+	ThreadModel BindJSONThreadModel `json:"thread-model"`
+}
+
+type BindJSONThreadModel struct {
+	WorkerThreads int `json:"worker-threads"`
+	TasksRunning  int `json:"tasks-running"`
+}
+
+// merge folds src, decoded from one statistics sub-resource, into root,
+// preserving whatever an earlier sub-resource already contributed to the
+// same view instead of letting a later decode's zero-valued fields
+// overwrite it.
+func (root *BindJSONRoot) merge(src BindJSONRoot) {
+	root.Opcodes = mergeCounterMap(root.Opcodes, src.Opcodes)
+	root.Qtypes = mergeCounterMap(root.Qtypes, src.Qtypes)
+	root.Nsstats = mergeCounterMap(root.Nsstats, src.Nsstats)
+
+	if src.Taskmgr.ThreadModel != (BindJSONThreadModel{}) {
+		root.Taskmgr = src.Taskmgr
+	}
+
+	if len(src.Views) == 0 {
+		return
+	}
+	if root.Views == nil {
+		root.Views = make(map[string]BindJSONView, len(src.Views))
+	}
+	for name, srcView := range src.Views {
+		view := root.Views[name]
+		view.Resolver.Cache = mergeCounterMap(view.Resolver.Cache, srcView.Resolver.Cache)
+		view.Resolver.Qtypes = mergeCounterMap(view.Resolver.Qtypes, srcView.Resolver.Qtypes)
+		view.Resolver.Stats = mergeCounterMap(view.Resolver.Stats, srcView.Resolver.Stats)
+
+		if len(srcView.Zones) > 0 {
+			if view.Zones == nil {
+				view.Zones = make(map[string]BindJSONZone, len(srcView.Zones))
+			}
+			for zoneName, zone := range srcView.Zones {
+				view.Zones[zoneName] = zone
+			}
+		}
+
+		root.Views[name] = view
+	}
+}
+
+// mergeCounterMap copies src's entries into dst, allocating dst if
+// needed, and leaves dst untouched when src is empty.
+func mergeCounterMap(dst, src map[string]uint64) map[string]uint64 {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]uint64, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// UpdateJSON populates the same descriptors as UpdateV3, sourced from the
+// JSON statistics channel instead of the XML v3 one.
+func (e *Exporter) UpdateJSON(ch chan<- prometheus.Metric, root BindJSONRoot) {
+	for name, v := range root.Qtypes {
+		ch <- prometheus.MustNewConstMetric(
+			incomingQueries, prometheus.CounterValue, float64(v), name,
+		)
+	}
+	for name, v := range root.Opcodes {
+		ch <- prometheus.MustNewConstMetric(
+			incomingRequests, prometheus.CounterValue, float64(v), name,
+		)
+	}
+	for name, v := range root.Nsstats {
+		if desc, ok := serverLabelStats[name]; ok {
+			r := strings.TrimPrefix(name, "Qry")
+			ch <- prometheus.MustNewConstMetric(
+				desc, prometheus.CounterValue, float64(v), r,
+			)
+		}
+	}
+
+	for viewName, view := range root.Views {
+		for name, v := range view.Resolver.Cache {
+			ch <- prometheus.MustNewConstMetric(
+				resolverCache, prometheus.GaugeValue, float64(v), viewName, name,
+			)
+		}
+
+		for name, v := range view.Resolver.Qtypes {
+			ch <- prometheus.MustNewConstMetric(
+				resolverQueries, prometheus.CounterValue, float64(v), viewName, name,
+			)
+		}
+
+		for name, v := range view.Resolver.Stats {
+			if desc, ok := resolverMetricStats[name]; ok {
+				ch <- prometheus.MustNewConstMetric(
+					desc, prometheus.CounterValue, float64(v), viewName,
+				)
+			}
+			if desc, ok := resolverLabelStats[name]; ok {
+				ch <- prometheus.MustNewConstMetric(
+					desc, prometheus.CounterValue, float64(v), viewName, name,
+				)
+			}
+		}
+
+		if buckets, count, err := histogramJSON(view.Resolver.Stats); err == nil {
+			ch <- prometheus.MustNewConstHistogram(
+				resolverQueryDuration, count, math.NaN(), buckets, viewName,
+			)
+		} else {
+			log.Warn("Error parsing RTT:", err)
+		}
+
+		e.updateZonesJSON(ch, viewName, view.Zones)
+	}
+
+	threadModel := root.Taskmgr.ThreadModel
+	ch <- prometheus.MustNewConstMetric(
+		tasksRunning, prometheus.GaugeValue, float64(threadModel.TasksRunning),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		workerThreads, prometheus.GaugeValue, float64(threadModel.WorkerThreads),
+	)
+}
+
+func histogramJSON(stats map[string]uint64) (map[float64]uint64, uint64, error) {
+	var err error
+	buckets := map[float64]uint64{}
+
+	for name, v := range stats {
+		if strings.HasPrefix(name, qryRTT) {
+			b := math.Inf(0)
+			if !strings.HasSuffix(name, "+") {
+				rrt := strings.TrimPrefix(name, qryRTT)
+				b, err = strconv.ParseFloat(rrt, 32)
+				if err != nil {
+					return buckets, 0, err
+				}
+			}
+			buckets[b/1000] = v
+		}
+	}
+
+	keys := make([]float64, len(buckets))
+	i := 0
+	for k := range buckets {
+		keys[i] = k
+		i++
+	}
+	sort.Float64s(keys)
+
+	count := uint64(0)
+	for _, k := range keys {
+		count += buckets[k]
+		buckets[k] = count
+	}
+
+	return buckets, count, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+// Regression test for a bug where decoding the "zones" sub-resource after
+// "server" wiped out the view's resolver stats, because encoding/json
+// replaces map values wholesale rather than merging into them.
+func TestBindJSONRootMergePreservesPriorViewData(t *testing.T) {
+	root := BindJSONRoot{}
+
+	root.merge(BindJSONRoot{
+		Views: map[string]BindJSONView{
+			"_default": {
+				Resolver: BindJSONResolver{
+					Stats: map[string]uint64{"Lame": 1},
+				},
+			},
+		},
+	})
+
+	root.merge(BindJSONRoot{
+		Views: map[string]BindJSONView{
+			"_default": {
+				Zones: map[string]BindJSONZone{
+					"example.com/IN": {Serial: 42},
+				},
+			},
+		},
+	})
+
+	view, ok := root.Views["_default"]
+	if !ok {
+		t.Fatal("expected _default view to survive the second merge")
+	}
+	if view.Resolver.Stats["Lame"] != 1 {
+		t.Fatalf("resolver stats lost after merging zones: %+v", view.Resolver.Stats)
+	}
+	if view.Zones["example.com/IN"].Serial != 42 {
+		t.Fatalf("zone data missing after merge: %+v", view.Zones)
+	}
+}
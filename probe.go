@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/log"
+)
+
+// probeConfig holds the modules made available to /probe. It is
+// populated once at startup, either from -config.file or from a single
+// "default" module synthesized from the bind.metrics/bind.timeout flags,
+// and is only ever read afterwards, so no locking is needed.
+var probeConfig = &Config{}
+
+// probeHandler implements a blackbox_exporter-style /probe endpoint: it
+// builds a fresh Exporter for the requested target/module and serves
+// metrics scoped to that one scrape, rather than the process-wide
+// Exporter registered under /metrics.
+func probeHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	target := params.Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	moduleName := params.Get("module")
+	module, ok := probeConfig.Module(moduleName)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown module %q", moduleName), http.StatusBadRequest)
+		return
+	}
+
+	zoneFilter, err := compileZoneFilter(module.ZoneFilter)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid zone_filter for module %q", moduleName), http.StatusInternalServerError)
+		return
+	}
+
+	exporter, err := NewExporter(target, ExporterOpts{
+		Metrics:     module.Metrics,
+		Timeout:     module.Timeout,
+		StatsFormat: module.StatsFormat,
+		ZoneFilter:  zoneFilter,
+		TLSConfig:   module.TLSConfig,
+		BasicAuth:   module.BasicAuth,
+	})
+	if err != nil {
+		log.Error("Error creating probe exporter: ", err)
+		http.Error(w, "error creating probe exporter", http.StatusInternalServerError)
+		return
+	}
+
+	registry := prometheus.NewRegistry()
+	if err := registry.Register(exporter); err != nil {
+		log.Error("Error registering probe exporter: ", err)
+		http.Error(w, "error registering probe exporter", http.StatusInternalServerError)
+		return
+	}
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
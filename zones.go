@@ -0,0 +1,144 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// compileZoneFilter compiles a -bind.zone-filter/zone_filter pattern, or
+// returns a nil *regexp.Regexp (matching every zone) if pattern is empty.
+func compileZoneFilter(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(pattern)
+}
+
+const zoneSubsystem = "zone"
+
+// ZoneV3 is a single <zone> element under a view's <zones> group in the
+// v3 XML statistics document.
+type ZoneV3 struct {
+	Name        string       `xml:"name"`
+	Serial      string       `xml:"serial"`
+	NotifyOut   uint64       `xml:"notifyout"`
+	JournalSize uint64       `xml:"journalsize"`
+	Counters    []CountersV3 `xml:"counters"`
+}
+
+var (
+	zoneSerial = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, zoneSubsystem, "serial"),
+		"SOA serial number of the zone.",
+		[]string{"view", "zone"}, nil,
+	)
+	zoneTransferSuccess = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, zoneSubsystem, "transfer_success_total"),
+		"Number of successful inbound zone transfers, by transfer type.",
+		[]string{"view", "zone", "type"}, nil,
+	)
+	zoneTransferFailure = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, zoneSubsystem, "transfer_failure_total"),
+		"Number of failed inbound zone transfers, by transfer type.",
+		[]string{"view", "zone", "type"}, nil,
+	)
+	zoneNotifyOut = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, zoneSubsystem, "notify_out_total"),
+		"Number of outbound NOTIFY messages sent for the zone.",
+		[]string{"view", "zone"}, nil,
+	)
+	zoneJournalSize = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, zoneSubsystem, "journal_size_bytes"),
+		"Size of the zone's journal file in bytes.",
+		[]string{"view", "zone"}, nil,
+	)
+)
+
+// zoneAllowed reports whether zone should be exported, honouring the
+// optional -bind.zone-filter regexp used to bound cardinality on servers
+// with very large numbers of zones.
+func (e *Exporter) zoneAllowed(name string) bool {
+	if e.zoneFilter == nil {
+		return true
+	}
+	return e.zoneFilter.MatchString(name)
+}
+
+// updateZonesV3 emits the per-zone descriptors for a single view's
+// <zones> element of the v3 XML statistics document.
+func (e *Exporter) updateZonesV3(ch chan<- prometheus.Metric, viewName string, zones []ZoneV3) {
+	for _, z := range zones {
+		if !e.zoneAllowed(z.Name) {
+			continue
+		}
+
+		if z.Serial != "" {
+			if serial, err := strconv.ParseFloat(z.Serial, 64); err == nil {
+				ch <- prometheus.MustNewConstMetric(
+					zoneSerial, prometheus.GaugeValue, serial, viewName, z.Name,
+				)
+			} else {
+				log.Warn("Error parsing zone serial:", err)
+			}
+		}
+
+		for _, counters := range z.Counters {
+			switch counters.Type {
+			case "xfrinsuccess":
+				for _, c := range counters.Counter {
+					ch <- prometheus.MustNewConstMetric(
+						zoneTransferSuccess, prometheus.CounterValue, float64(c.Counter), viewName, z.Name, c.Name,
+					)
+				}
+			case "xfrinfailure":
+				for _, c := range counters.Counter {
+					ch <- prometheus.MustNewConstMetric(
+						zoneTransferFailure, prometheus.CounterValue, float64(c.Counter), viewName, z.Name, c.Name,
+					)
+				}
+			}
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			zoneNotifyOut, prometheus.CounterValue, float64(z.NotifyOut), viewName, z.Name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			zoneJournalSize, prometheus.GaugeValue, float64(z.JournalSize), viewName, z.Name,
+		)
+	}
+}
+
+// updateZonesJSON is the JSON-statistics-channel counterpart to
+// updateZonesV3.
+func (e *Exporter) updateZonesJSON(ch chan<- prometheus.Metric, viewName string, zones map[string]BindJSONZone) {
+	for name, z := range zones {
+		if !e.zoneAllowed(name) {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			zoneSerial, prometheus.GaugeValue, float64(z.Serial), viewName, name,
+		)
+
+		for transferType, v := range z.XfrInSuccess {
+			ch <- prometheus.MustNewConstMetric(
+				zoneTransferSuccess, prometheus.CounterValue, float64(v), viewName, name, transferType,
+			)
+		}
+		for transferType, v := range z.XfrInFailure {
+			ch <- prometheus.MustNewConstMetric(
+				zoneTransferFailure, prometheus.CounterValue, float64(v), viewName, name, transferType,
+			)
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			zoneNotifyOut, prometheus.CounterValue, float64(z.NotifyOut), viewName, name,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			zoneJournalSize, prometheus.GaugeValue, float64(z.JournalSize), viewName, name,
+		)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestCompileZoneFilter(t *testing.T) {
+	t.Run("empty pattern matches everything", func(t *testing.T) {
+		re, err := compileZoneFilter("")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if re != nil {
+			t.Fatalf("expected a nil *regexp.Regexp, got %v", re)
+		}
+	})
+
+	t.Run("valid pattern compiles", func(t *testing.T) {
+		re, err := compileZoneFilter("^example\\.")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if re == nil {
+			t.Fatal("expected a compiled *regexp.Regexp")
+		}
+	})
+
+	t.Run("invalid pattern errors", func(t *testing.T) {
+		if _, err := compileZoneFilter("("); err == nil {
+			t.Fatal("expected an error for an unbalanced regexp")
+		}
+	})
+}
+
+func TestZoneAllowed(t *testing.T) {
+	t.Run("nil filter allows everything", func(t *testing.T) {
+		e := &Exporter{}
+		if !e.zoneAllowed("example.com") {
+			t.Error("expected a nil zoneFilter to allow every zone")
+		}
+	})
+
+	t.Run("filter restricts to matching zones", func(t *testing.T) {
+		re, err := compileZoneFilter("^example\\.")
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := &Exporter{zoneFilter: re}
+
+		if !e.zoneAllowed("example.com") {
+			t.Error("expected example.com to be allowed")
+		}
+		if e.zoneAllowed("other.com") {
+			t.Error("expected other.com to be rejected")
+		}
+	})
+}